@@ -0,0 +1,133 @@
+package memdb
+
+// DefaultMaxChangesPerBatch is the number of buffered Set/Delete calls a
+// Batch accumulates in a single underlying transaction before
+// committing it and starting a fresh one, bounding both commit size and
+// per-transaction memory for bulk loads.
+const DefaultMaxChangesPerBatch = 200
+
+// Batch buffers Set/Delete operations across a chunk of inner
+// transactions, transparently committing the current one and starting a
+// new one whenever MaxChangesPerBatch is reached. This lets callers
+// ingest bulk data without holding a single transaction's buffered
+// writes open indefinitely.
+type Batch struct {
+	db *Database
+	tx *Transaction
+
+	MaxChangesPerBatch int
+
+	changes int
+	total   int
+}
+
+// Batch starts a new Batch that writes through tx's Database, beginning
+// with tx as its first chunk. tx should not be used directly once handed
+// to Batch.
+func (tx *Transaction) Batch() *Batch {
+	return &Batch{
+		db:                 tx.db,
+		tx:                 tx,
+		MaxChangesPerBatch: DefaultMaxChangesPerBatch,
+	}
+}
+
+// Update runs fn against a fresh Batch backed by its own writable
+// transaction, committing the final chunk when fn returns nil and
+// rolling back the in-flight chunk otherwise.
+func (db *Database) Update(fn func(*Batch) error) error {
+	b := db.Begin(true).Batch()
+
+	if err := fn(b); err != nil {
+		b.tx.Rollback()
+		return err
+	}
+
+	return b.commit()
+}
+
+// Set is the batched equivalent of Transaction.Set.
+func (b *Batch) Set(key Key, value string) error {
+	if err := b.tx.Set(key, value); err != nil {
+		return err
+	}
+	return b.bump()
+}
+
+// Delete is the batched equivalent of Transaction.Delete.
+func (b *Batch) Delete(key Key) error {
+	if err := b.tx.Delete(key); err != nil {
+		return err
+	}
+	return b.bump()
+}
+
+func (b *Batch) bump() error {
+	b.changes++
+	b.total++
+
+	if b.MaxChangesPerBatch <= 0 || b.changes < b.MaxChangesPerBatch {
+		return nil
+	}
+
+	return b.split()
+}
+
+// split commits the current chunk and opens a new writable transaction
+// to keep buffering into, preserving write ordering across the split.
+func (b *Batch) split() error {
+	if err := b.commitChunk(); err != nil {
+		return err
+	}
+
+	b.tx = b.db.Begin(true)
+	b.changes = 0
+
+	return nil
+}
+
+func (b *Batch) commit() error {
+	return b.commitChunk()
+}
+
+// commitChunk commits b.tx, retrying with a fresh transaction replaying
+// the same buffered writes if it aborts with ErrConflict, the same way
+// Database.RunInTx does for a single transaction. Without this, a
+// transient conflict on one chunk would silently drop just that chunk
+// while earlier chunks of the same Batch stayed committed, breaking
+// atomicity across the whole batch.
+func (b *Batch) commitChunk() error {
+	const maxBatchRetries = 10
+
+	tx := b.tx
+	var err error
+	for i := 0; i < maxBatchRetries; i++ {
+		if err = tx.Commit(); err != ErrConflict {
+			return err
+		}
+
+		retry := b.db.Begin(true)
+		for key, item := range tx.pending {
+			if item.deletedTx != 0 {
+				retry.Delete(key)
+			} else {
+				retry.Set(key, item.value)
+			}
+		}
+		tx = retry
+	}
+
+	return err
+}
+
+// Size reports the number of changes buffered in the current,
+// not-yet-committed chunk.
+func (b *Batch) Size() int {
+	return b.changes
+}
+
+// Len reports the total number of changes applied across every chunk of
+// this Batch so far, including ones already committed by an auto-split.
+func (b *Batch) Len() int {
+	return b.total
+}
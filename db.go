@@ -1,12 +1,13 @@
 package memdb
 
 import (
+	"fmt"
 	"math"
+	"os"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
-
-	"github.com/tidwall/btree"
 )
 
 type Key string
@@ -22,24 +23,6 @@ type dbItem struct {
 	deletedOperation uint64
 }
 
-func (i *dbItem) Less(item btree.Item, ctx interface{}) bool {
-	i2 := item.(*dbItem)
-	index, ok := ctx.(*Index)
-	if ok {
-		if index.sortFn != nil {
-			// Using an Index
-			if index.sortFn(i.value, i2.value) {
-				return true
-			}
-			if index.sortFn(i2.value, i.value) {
-				return false
-			}
-		}
-	}
-
-	return i.key < i2.key
-}
-
 type Items struct {
 	mu      sync.RWMutex
 	storage map[Key][]*dbItem
@@ -63,6 +46,20 @@ func (it *Items) get(key Key) []dbItem {
 	return itemsCopy
 }
 
+// supersede marks every currently live (deletedTx == 0) version of key
+// as deleted as of commitTs, so that once the new version for commitTs
+// is stored, at most one live version of the key remains (none, if the
+// new version is a delete).
+func (it *Items) supersede(key Key, commitTs uint64) {
+	it.mu.Lock()
+	for _, item := range it.storage[key] {
+		if item.deletedTx == 0 {
+			item.deletedTx = commitTs
+		}
+	}
+	it.mu.Unlock()
+}
+
 func (it *Items) keys() []Key {
 	keys := make([]Key, 0)
 
@@ -76,23 +73,176 @@ func (it *Items) keys() []Key {
 }
 
 type Database struct {
-	writeTx sync.Mutex
+	items Items
 
-	items   Items
-	indexes *Indexes
+	// indexes is swapped for a freshly published copy on every committing
+	// Transaction, guarded by indexesMu rather than indexes.mu itself, so
+	// a commit's read-clone-apply-swap sequence always starts from the
+	// latest published indexes rather than racing another commit that
+	// swapped indexes out from under it.
+	indexes   *Indexes
+	indexesMu sync.Mutex
+
+	oracle      *oracle
+	persistence Persistence
+	watchers    *watchers
 
 	writers txsStatus
 	lastTx  uint64
+
+	// MaxVersionsPerKey caps how many versions of a single key RunGC
+	// keeps. Once the versions it retains for running transactions exceed
+	// the cap, the oldest are dropped even if a running transaction could
+	// still read them; 0 means unlimited.
+	MaxVersionsPerKey int
+
+	// lastUpdatedUnixNano, bytesReclaimed and entriesReclaimed are
+	// updated with atomic stores only, so Stats() can read them without
+	// contending with writers on any mutex.
+	lastUpdatedUnixNano int64
+	bytesReclaimed      int64
+	entriesReclaimed    int64
 }
 
+// primaryIndex is the name of the always-present index ordered by key
+// alone, used for Ascend/Descend/AscendRange/AscendPrefix scans that
+// don't need a custom SortFunc.
+const primaryIndex = ""
+
 func NewDB() *Database {
+	indexes := newIndexer()
+	indexes.byName[primaryIndex] = newIndex(primaryIndex, nil)
+
 	return &Database{
-		items:   Items{storage: make(map[Key][]*dbItem)},
-		indexes: newIndexer(),
-		writers: txsStatus{txs: make(map[uint64]Status)},
+		items:       Items{storage: make(map[Key][]*dbItem)},
+		indexes:     indexes,
+		oracle:      newOracle(),
+		persistence: NopPersistence{},
+		watchers:    newWatchers(),
+		writers:     txsStatus{txs: make(map[uint64]Status)},
+	}
+}
+
+// NewDBFromFile opens, creating if necessary, a durable Database backed
+// by a write-ahead log at walPath. Existing state is rebuilt by
+// replaying the last snapshot (at walPath + ".snapshot") followed by the
+// tail of the WAL, in commit-timestamp order, so MVCC visibility and
+// RunGC behave the same as if the process had never restarted.
+func NewDBFromFile(walPath string) (*Database, error) {
+	db := NewDB()
+
+	persistence, err := NewFilePersistence(walPath, db)
+	if err != nil {
+		return nil, err
+	}
+	db.persistence = persistence
+
+	if f, err := os.Open(walPath + ".snapshot"); err == nil {
+		err = persistence.Restore(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("memdb: open snapshot: %w", err)
+	}
+
+	// Restore already loaded everything as of snapshotTs; replaying those
+	// same records again from the WAL would re-supersede newer items with
+	// older timestamps and lose them (see replayWAL's caller below).
+	snapshotTs := db.lastTx
+
+	if err := persistence.replayWAL(func(rec walRecord) {
+		if rec.TxID <= snapshotTs {
+			return
+		}
+
+		tx := db.Begin(true)
+		for _, op := range rec.Ops {
+			switch op.Type {
+			case OpSet:
+				tx.Set(op.Key, op.Value)
+			case OpDelete:
+				tx.Delete(op.Key)
+			}
+		}
+		for key, item := range tx.pending {
+			item.createdTx = rec.TxID
+			if item.deletedTx != 0 {
+				item.deletedTx = rec.TxID
+			}
+			db.items.supersede(key, rec.TxID)
+			db.items.set(key, item)
+		}
+		tx.done = true
+		db.writers.set(tx.id, StatusDone)
+		db.oracle.done(tx.readTs)
+		if rec.TxID > db.lastTx {
+			db.lastTx = rec.TxID
+		}
+	}); err != nil {
+		return nil, err
 	}
+
+	// Restored items keep their real createdTx/deletedTx values (e.g. 1,
+	// 50, ...), but a fresh oracle always starts readTs/commitTs at 0, so
+	// every one of them would fail Transaction.visible's createdTx >
+	// readTs check for any transaction begun after restart. Seed the
+	// oracle to the highest tx id actually restored so visibility and
+	// future commit timestamps pick up where the prior process left off.
+	db.oracle.seed(db.lastTx)
+
+	db.rebuildIndexes()
+
+	return db, nil
+}
+
+// rebuildIndexes repopulates every registered index from the current
+// contents of db.items.storage. Restore and WAL replay write straight
+// into items.storage and never touch db.indexes, so without this every
+// Ascend/Descend/AscendRange/AscendPrefix call would silently see nothing
+// after a restart even though Get still works fine. It iterates storage
+// directly, rather than through Items.get, so each index entry is the
+// same *dbItem Commit itself uses rather than a copy that could drift.
+func (db *Database) rebuildIndexes() {
+	for _, idx := range db.indexes.byName {
+		idx.data = newItemTree(idx.less)
+	}
+
+	db.items.mu.RLock()
+	defer db.items.mu.RUnlock()
+
+	for _, items := range db.items.storage {
+		for _, item := range items {
+			for _, idx := range db.indexes.byName {
+				idx.data.ReplaceOrInsert(item)
+			}
+		}
+	}
+}
+
+// Checkpoint writes a fresh snapshot of the current state to
+// walPath + ".snapshot", where walPath is whatever path the Database was
+// opened with via NewDBFromFile. It is a no-op for a Database created
+// with NewDB.
+func (db *Database) Checkpoint(walPath string) error {
+	if _, ok := db.persistence.(NopPersistence); ok {
+		return nil
+	}
+
+	f, err := os.Create(walPath + ".snapshot")
+	if err != nil {
+		return fmt.Errorf("memdb: create snapshot: %w", err)
+	}
+	defer f.Close()
+
+	return db.persistence.Snapshot(f)
 }
 
+// Begin starts a new transaction. Read-only transactions (writable =
+// false) see a stable snapshot and never conflict with anything.
+// Writable transactions run concurrently with one another; conflicts
+// are only detected, and only abort, at Commit time (see oracle).
 func (db *Database) Begin(writable bool) *Transaction {
 	txID := atomic.AddUint64(&db.lastTx, 1)
 
@@ -101,14 +251,16 @@ func (db *Database) Begin(writable bool) *Transaction {
 	}
 
 	tx := &Transaction{
-		id: txID,
-		db: db,
+		id:     txID,
+		db:     db,
+		readTs: db.oracle.begin(),
 	}
 
 	if writable {
-		db.writeTx.Lock()
 		tx.writable = true
+		db.indexesMu.Lock()
 		tx.newIndexes = db.indexes.Copy()
+		db.indexesMu.Unlock()
 	}
 
 	db.writers.set(txID, StatusRunning)
@@ -116,15 +268,92 @@ func (db *Database) Begin(writable bool) *Transaction {
 	return tx
 }
 
-func (db *Database) background() {
-	t := time.NewTicker(time.Minute * 5)
+// CreateIndex registers a named secondary index ordered by sortFn, and
+// backfills it with every version of every key already in the database.
+// Future writes keep it up to date.
+func (db *Database) CreateIndex(name string, sortFn SortFunc) error {
+	db.indexesMu.Lock()
+	defer db.indexesMu.Unlock()
+
+	db.indexes.mu.Lock()
+	if _, exists := db.indexes.byName[name]; exists {
+		db.indexes.mu.Unlock()
+		return fmt.Errorf("memdb: index %q already exists", name)
+	}
+	idx := newIndex(name, sortFn)
+	db.indexes.byName[name] = idx
+	db.indexes.mu.Unlock()
+
+	db.items.mu.RLock()
+	for _, items := range db.items.storage {
+		for _, item := range items {
+			idx.data.ReplaceOrInsert(item)
+		}
+	}
+	db.items.mu.RUnlock()
+
+	return nil
+}
 
-	for range t.C {
-		db.cleanOutdated()
+// RunInTx runs fn in a new writable transaction, committing on success
+// and automatically retrying with a fresh transaction if it aborts with
+// ErrConflict. It gives up and returns ErrConflict after maxRunInTxRetries
+// attempts.
+func (db *Database) RunInTx(fn func(*Transaction) error) error {
+	const maxRunInTxRetries = 10
+
+	var err error
+	for i := 0; i < maxRunInTxRetries; i++ {
+		tx := db.Begin(true)
+
+		if err = fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err = tx.Commit(); err != ErrConflict {
+			return err
+		}
 	}
+
+	return err
 }
 
-func (db *Database) cleanOutdated() {
+// StartGC starts a background goroutine that calls RunGC every interval,
+// replacing the old hard-coded 5-minute loop that no exported API could
+// ever start or stop. The returned stop func halts it; it is safe to
+// call more than once.
+func (db *Database) StartGC(interval time.Duration) (stop func()) {
+	t := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-t.C:
+				db.RunGC()
+			case <-done:
+				t.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// RunGC synchronously runs one compaction pass: it drops item versions
+// no longer visible to any running transaction, and, if MaxVersionsPerKey
+// is set, also drops the oldest invisible-to-future-reads versions of
+// any key that still exceeds the cap afterwards. Every dropped version is
+// also removed from the primary and every secondary index, so it is
+// actually reclaimed rather than staying reachable through a range scan.
+// It returns how many entries and bytes it reclaimed, and also records
+// them for Stats.
+func (db *Database) RunGC() (entriesReclaimed, bytesReclaimed int64) {
 	in := func(need uint64, items []uint64) bool {
 		for _, item := range items {
 			if item == need {
@@ -136,21 +365,42 @@ func (db *Database) cleanOutdated() {
 	}
 
 	running := db.writers.running()
+	// A superseded version is still visible to every active transaction
+	// whose read timestamp is older than its deletedTx.
+	db.oracle.writeLock.Lock()
+	oldestRead := db.oracle.readTs
+	if len(db.oracle.activeReads) > 0 {
+		oldestRead = db.oracle.activeReads[0]
+	}
+	db.oracle.writeLock.Unlock()
+	maxVersions := db.MaxVersionsPerKey
+	dropped := make(map[*dbItem]struct{})
 
 	db.items.mu.Lock()
 	for key, items := range db.items.storage {
-		actual := make([]*dbItem, 0)
+		actual := make([]*dbItem, 0, len(items))
 
 		for _, item := range items {
-			if item.deletedTx == 0 {
+			if item.deletedTx == 0 || item.deletedTx > oldestRead || in(item.deletedTx, running) {
 				actual = append(actual, item)
 				continue
 			}
 
-			if in(item.deletedTx, running) {
-				actual = append(actual, item)
-				continue
+			dropped[item] = struct{}{}
+			entriesReclaimed++
+			bytesReclaimed += int64(len(item.key)) + int64(len(item.value))
+		}
+
+		if maxVersions > 0 && len(actual) > maxVersions {
+			drop := len(actual) - maxVersions
+			sort.Slice(actual, func(i, j int) bool { return actual[i].createdTx < actual[j].createdTx })
+
+			for _, item := range actual[:drop] {
+				dropped[item] = struct{}{}
+				entriesReclaimed++
+				bytesReclaimed += int64(len(item.key)) + int64(len(item.value))
 			}
+			actual = actual[drop:]
 		}
 
 		if len(actual) == len(items) {
@@ -160,6 +410,74 @@ func (db *Database) cleanOutdated() {
 		db.items.storage[key] = actual
 	}
 	db.items.mu.Unlock()
+
+	if len(dropped) > 0 {
+		db.indexesMu.Lock()
+		published := db.indexes.Copy()
+		for _, idx := range published.byName {
+			idx.data.Remove(dropped)
+		}
+		db.indexes = published
+		db.indexesMu.Unlock()
+	}
+
+	atomic.StoreInt64(&db.entriesReclaimed, entriesReclaimed)
+	atomic.StoreInt64(&db.bytesReclaimed, bytesReclaimed)
+
+	return entriesReclaimed, bytesReclaimed
+}
+
+// Stats is a point-in-time snapshot of a Database's size and activity.
+// The tx counts, reclaim counters, dropped-watch count and last-write
+// time are atomic loads. LiveKeys and TotalVersions are counted by
+// scanning the items map under its read lock, so that scan waits for,
+// and delays, a concurrent Commit's write to the same map.
+type Stats struct {
+	LiveKeys         int
+	TotalVersions    int
+	RunningTxs       int64
+	RolledBackTxs    int64
+	EntriesReclaimed int64
+	BytesReclaimed   int64
+	SinceLastWrite   time.Duration
+	WatchesDropped   int64
+}
+
+func (db *Database) Stats() Stats {
+	running, _, rollback := db.writers.counts()
+
+	db.items.mu.RLock()
+	liveKeys, totalVersions := 0, 0
+	for _, items := range db.items.storage {
+		totalVersions += len(items)
+
+		live := false
+		for _, item := range items {
+			if item.deletedTx == 0 {
+				live = true
+			}
+		}
+		if live {
+			liveKeys++
+		}
+	}
+	db.items.mu.RUnlock()
+
+	var sinceLastWrite time.Duration
+	if last := atomic.LoadInt64(&db.lastUpdatedUnixNano); last != 0 {
+		sinceLastWrite = time.Since(time.Unix(0, last))
+	}
+
+	return Stats{
+		LiveKeys:         liveKeys,
+		TotalVersions:    totalVersions,
+		RunningTxs:       running,
+		RolledBackTxs:    rollback,
+		EntriesReclaimed: atomic.LoadInt64(&db.entriesReclaimed),
+		BytesReclaimed:   atomic.LoadInt64(&db.bytesReclaimed),
+		SinceLastWrite:   sinceLastWrite,
+		WatchesDropped:   atomic.LoadInt64(&db.watchers.dropped),
+	}
 }
 
 type Status int8
@@ -171,10 +489,17 @@ const (
 	StatusRollback
 )
 
-// txsStatus is storing current writing transactions state
+// txsStatus is storing current writing transactions state. Alongside
+// the map (needed by RunGC to know exactly which tx IDs are
+// still running), it keeps a per-status count as atomic counters, so
+// Stats() can report tx counts without ever taking txsStatus's mutex.
 type txsStatus struct {
 	txs map[uint64]Status
 	mu  sync.RWMutex
+
+	runningCount  int64
+	doneCount     int64
+	rollbackCount int64
 }
 
 func (atx *txsStatus) get(tx uint64) Status {
@@ -185,8 +510,23 @@ func (atx *txsStatus) get(tx uint64) Status {
 
 func (atx *txsStatus) set(tx uint64, status Status) {
 	atx.mu.Lock()
-	defer atx.mu.Unlock()
+	prev := atx.txs[tx]
 	atx.txs[tx] = status
+	atx.mu.Unlock()
+
+	atx.adjustCount(prev, -1)
+	atx.adjustCount(status, 1)
+}
+
+func (atx *txsStatus) adjustCount(status Status, delta int64) {
+	switch status {
+	case StatusRunning:
+		atomic.AddInt64(&atx.runningCount, delta)
+	case StatusDone:
+		atomic.AddInt64(&atx.doneCount, delta)
+	case StatusRollback:
+		atomic.AddInt64(&atx.rollbackCount, delta)
+	}
 }
 
 func (atx *txsStatus) running() []uint64 {
@@ -200,3 +540,11 @@ func (atx *txsStatus) running() []uint64 {
 	atx.mu.RUnlock()
 	return running
 }
+
+// counts reports live tx counts per status without taking atx.mu, so it
+// never blocks a concurrent writer calling set.
+func (atx *txsStatus) counts() (running, done, rollback int64) {
+	return atomic.LoadInt64(&atx.runningCount),
+		atomic.LoadInt64(&atx.doneCount),
+		atomic.LoadInt64(&atx.rollbackCount)
+}
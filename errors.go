@@ -0,0 +1,17 @@
+package memdb
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when a key has no value visible to the
+	// transaction's snapshot.
+	ErrNotFound = errors.New("memdb: key not found")
+
+	// ErrTxNotWritable is returned by mutating Transaction methods when
+	// called on a transaction started with Begin(false).
+	ErrTxNotWritable = errors.New("memdb: transaction is not writable")
+
+	// ErrTxDone is returned when Commit or a mutating method is called
+	// on a transaction that has already committed or rolled back.
+	ErrTxDone = errors.New("memdb: transaction already committed or rolled back")
+)
@@ -0,0 +1,173 @@
+package memdb
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// FilePersistence is a Persistence backed by an append-only
+// write-ahead log file and a gzip'd snapshot file, giving a Database
+// durability across restarts.
+type FilePersistence struct {
+	db *Database
+
+	mu      sync.Mutex
+	walPath string
+	wal     *os.File
+	enc     *gob.Encoder
+}
+
+// walRecord is one entry in the write-ahead log: the ops committed by a
+// single writable transaction.
+type walRecord struct {
+	TxID uint64
+	Ops  []Op
+}
+
+// persistedItem mirrors dbItem with exported fields, since gob cannot
+// encode the unexported fields dbItem uses internally.
+type persistedItem struct {
+	Key   Key
+	Value string
+
+	CreatedTx uint64
+	DeletedTx uint64
+
+	CreatedOperation uint64
+	DeletedOperation uint64
+}
+
+// snapshotData is the full state captured by Snapshot and replayed by Restore.
+type snapshotData struct {
+	Items  map[Key][]persistedItem
+	LastTx uint64
+}
+
+// NewFilePersistence opens (creating if necessary) the WAL at walPath
+// for appending. db is the Database this Persistence will back; its
+// state is what Snapshot captures and Restore replaces.
+func NewFilePersistence(walPath string, db *Database) (*FilePersistence, error) {
+	f, err := os.OpenFile(walPath, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("memdb: open wal: %w", err)
+	}
+
+	return &FilePersistence{
+		db:      db,
+		walPath: walPath,
+		wal:     f,
+		enc:     gob.NewEncoder(f),
+	}, nil
+}
+
+// AppendCommit appends ops to the WAL and fsyncs before returning, so a
+// successful call guarantees the commit survives a crash.
+func (p *FilePersistence) AppendCommit(txID uint64, ops []Op) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.enc.Encode(walRecord{TxID: txID, Ops: ops}); err != nil {
+		return fmt.Errorf("memdb: append wal: %w", err)
+	}
+	return p.wal.Sync()
+}
+
+// Snapshot gzip-compresses a gob encoding of the backing database's
+// current items. Callers normally reach this through Database.Checkpoint
+// rather than directly.
+func (p *FilePersistence) Snapshot(w io.Writer) error {
+	data := snapshotData{Items: make(map[Key][]persistedItem)}
+
+	p.db.items.mu.RLock()
+	for key, items := range p.db.items.storage {
+		versions := make([]persistedItem, 0, len(items))
+		for _, item := range items {
+			versions = append(versions, persistedItem{
+				Key:              item.key,
+				Value:            item.value,
+				CreatedTx:        item.createdTx,
+				DeletedTx:        item.deletedTx,
+				CreatedOperation: item.createdOperation,
+				DeletedOperation: item.deletedOperation,
+			})
+		}
+		data.Items[key] = versions
+	}
+	p.db.items.mu.RUnlock()
+
+	data.LastTx = atomic.LoadUint64(&p.db.lastTx)
+
+	gz := gzip.NewWriter(w)
+	if err := gob.NewEncoder(gz).Encode(data); err != nil {
+		return fmt.Errorf("memdb: write snapshot: %w", err)
+	}
+	return gz.Close()
+}
+
+// Restore decodes a gzip'd snapshot previously written by Snapshot and
+// replaces the backing database's items with it.
+func (p *FilePersistence) Restore(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("memdb: read snapshot: %w", err)
+	}
+	defer gz.Close()
+
+	var data snapshotData
+	if err := gob.NewDecoder(gz).Decode(&data); err != nil {
+		return fmt.Errorf("memdb: decode snapshot: %w", err)
+	}
+
+	storage := make(map[Key][]*dbItem, len(data.Items))
+	for key, versions := range data.Items {
+		items := make([]*dbItem, 0, len(versions))
+		for _, v := range versions {
+			items = append(items, &dbItem{
+				key:              v.Key,
+				value:            v.Value,
+				createdTx:        v.CreatedTx,
+				deletedTx:        v.DeletedTx,
+				createdOperation: v.CreatedOperation,
+				deletedOperation: v.DeletedOperation,
+			})
+		}
+		storage[key] = items
+	}
+
+	p.db.items.mu.Lock()
+	p.db.items.storage = storage
+	p.db.items.mu.Unlock()
+
+	atomic.StoreUint64(&p.db.lastTx, data.LastTx)
+
+	return nil
+}
+
+// replayWAL reads every record appended to the WAL and hands it to fn in order.
+func (p *FilePersistence) replayWAL(fn func(walRecord)) error {
+	f, err := os.Open(p.walPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("memdb: open wal: %w", err)
+	}
+	defer f.Close()
+
+	dec := gob.NewDecoder(f)
+	for {
+		var rec walRecord
+		if err := dec.Decode(&rec); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("memdb: replay wal: %w", err)
+		}
+		fn(rec)
+	}
+}
@@ -0,0 +1,75 @@
+package memdb
+
+import "sync"
+
+// SortFunc orders two values for a secondary index. It follows the
+// standard "less than" convention: it reports whether a sorts before b.
+type SortFunc func(a, b string) bool
+
+// Index is a secondary ordering over the values stored under a single
+// key namespace, backed by an itemTree so it supports range scans.
+type Index struct {
+	name   string
+	sortFn SortFunc
+	data   *itemTree
+}
+
+func newIndex(name string, sortFn SortFunc) *Index {
+	idx := &Index{name: name, sortFn: sortFn}
+	idx.data = newItemTree(idx.less)
+	return idx
+}
+
+// less orders two dbItems the way this index keeps them sorted: by
+// sortFn if one is set, falling back to key, and finally — since several
+// MVCC versions of a key can coexist in the tree — by createdTx, newest
+// first, so range scans hit the newest version of a key before older
+// ones.
+func (idx *Index) less(a, b *dbItem) bool {
+	if idx.sortFn != nil {
+		if idx.sortFn(a.value, b.value) {
+			return true
+		}
+		if idx.sortFn(b.value, a.value) {
+			return false
+		}
+	}
+
+	if a.key != b.key {
+		return a.key < b.key
+	}
+	return a.createdTx > b.createdTx
+}
+
+// Indexes is the set of secondary indexes registered on a Database. It is
+// copy-on-write: a writable Transaction works against its own Copy and
+// only the committed copy is ever visible to readers.
+type Indexes struct {
+	mu     sync.RWMutex
+	byName map[string]*Index
+}
+
+func newIndexer() *Indexes {
+	return &Indexes{byName: make(map[string]*Index)}
+}
+
+// Copy returns a copy-on-write clone of the index set: each index's
+// sorted slice is copied in full (O(n)), so a writable transaction can insert into its
+// own copy without affecting concurrent readers, and the clone becomes
+// the database's index set once the transaction commits.
+func (ix *Indexes) Copy() *Indexes {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	cp := newIndexer()
+	for name, idx := range ix.byName {
+		cp.byName[name] = &Index{name: idx.name, sortFn: idx.sortFn, data: idx.data.Clone()}
+	}
+	return cp
+}
+
+func (ix *Indexes) get(name string) *Index {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.byName[name]
+}
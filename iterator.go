@@ -0,0 +1,143 @@
+package memdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxTs sorts before every real version of a key (see Index.less's
+// createdTx tiebreak), so a range scan's pivot always lands at the start
+// of that key's MVCC version cluster rather than in the middle of it.
+const maxTs = ^uint64(0)
+
+func pivotItem(pivot string) *dbItem {
+	return &dbItem{key: Key(pivot), value: pivot, createdTx: maxTs}
+}
+
+// indexSet returns the index snapshot this transaction reads from: its
+// own copy-on-write set if writable, or the database's committed set if
+// read-only.
+func (tx *Transaction) indexSet() *Indexes {
+	if tx.writable {
+		return tx.newIndexes
+	}
+	return tx.db.indexes
+}
+
+// visit wraps a caller's fn so that the several MVCC versions a key may
+// have in the tree collapse into whichever one is visible to tx, each
+// key reported at most once, and deleted keys skipped entirely. For a
+// writable tx, a key with a buffered Set/Delete is always resolved from
+// tx.pending instead, giving range scans the same read-your-writes
+// behavior Get already has for point lookups.
+func (tx *Transaction) visit(fn func(key Key, value string) bool) func(item *dbItem) bool {
+	var lastKey Key
+	var resolved bool
+
+	return func(di *dbItem) bool {
+		if di.key != lastKey {
+			lastKey = di.key
+			resolved = false
+		}
+		if resolved {
+			return true
+		}
+
+		if tx.writable {
+			if pending, ok := tx.pending[di.key]; ok {
+				if di != pending {
+					return true // a version shadowed by the buffered write
+				}
+				resolved = true
+				if pending.deletedTx != 0 {
+					return true // buffered delete: key is absent
+				}
+				return fn(di.key, pending.value)
+			}
+		}
+
+		if !tx.visible(di) {
+			return true // not yet visible to tx; fall through to the next, older version
+		}
+		// A visible version is live as of readTs even if a later commit has
+		// since set its deletedTx; a committed delete is never visible itself.
+		resolved = true
+
+		return fn(di.key, di.value)
+	}
+}
+
+// Ascend walks indexName in ascending order, starting at pivot (or from
+// the beginning if pivot is ""), calling fn with the version of each key
+// visible to tx. Iteration stops early if fn returns false.
+func (tx *Transaction) Ascend(indexName string, pivot string, fn func(key Key, value string) bool) error {
+	idx := tx.indexSet().get(indexName)
+	if idx == nil {
+		return fmt.Errorf("memdb: no such index %q", indexName)
+	}
+
+	visit := tx.visit(fn)
+	if pivot == "" {
+		idx.data.Ascend(visit)
+	} else {
+		idx.data.AscendGreaterOrEqual(pivotItem(pivot), visit)
+	}
+	return nil
+}
+
+// Descend is the descending-order counterpart of Ascend.
+func (tx *Transaction) Descend(indexName string, pivot string, fn func(key Key, value string) bool) error {
+	idx := tx.indexSet().get(indexName)
+	if idx == nil {
+		return fmt.Errorf("memdb: no such index %q", indexName)
+	}
+
+	visit := tx.visit(fn)
+	if pivot == "" {
+		idx.data.Descend(visit)
+	} else {
+		// createdTx 0 sorts after every version of pivot's key, so that
+		// key is included, as it is in Ascend.
+		idx.data.DescendLessOrEqual(&dbItem{key: Key(pivot), value: pivot}, visit)
+	}
+	return nil
+}
+
+// AscendRange walks indexName in ascending order over the half-open
+// range from greaterOrEqual up to, but not including, lessThan.
+func (tx *Transaction) AscendRange(indexName, greaterOrEqual, lessThan string, fn func(key Key, value string) bool) error {
+	idx := tx.indexSet().get(indexName)
+	if idx == nil {
+		return fmt.Errorf("memdb: no such index %q", indexName)
+	}
+
+	idx.data.AscendRange(pivotItem(greaterOrEqual), pivotItem(lessThan), tx.visit(fn))
+	return nil
+}
+
+// AscendPrefix walks indexName in ascending order over every key with
+// the given prefix. It only makes sense against the primary (key-ordered)
+// index, since a custom sortFn orders by value rather than by key.
+func (tx *Transaction) AscendPrefix(indexName, prefix string, fn func(key Key, value string) bool) error {
+	idx := tx.indexSet().get(indexName)
+	if idx == nil {
+		return fmt.Errorf("memdb: no such index %q", indexName)
+	}
+
+	visit := tx.visit(fn)
+	idx.data.AscendGreaterOrEqual(pivotItem(prefix), func(item *dbItem) bool {
+		if !strings.HasPrefix(string(item.key), prefix) {
+			return false
+		}
+		return visit(item)
+	})
+	return nil
+}
+
+// SeekStart walks indexName in ascending order from the very beginning,
+// calling fn with the version of each key visible to tx. It is
+// equivalent to Ascend(indexName, "", fn), named to mirror the
+// seek-to-start API real KV stores expose.
+func (tx *Transaction) SeekStart(indexName string, fn func(key Key, value string) bool) error {
+	return tx.Ascend(indexName, "", fn)
+}
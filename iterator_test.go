@@ -0,0 +1,105 @@
+package memdb
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestAscendSnapshotIgnoresConcurrentCommits checks that a range scan
+// over a snapshot taken before a burst of concurrent writers sees exactly
+// that snapshot once they have all committed, thanks to MVCC visibility.
+func TestAscendSnapshotIgnoresConcurrentCommits(t *testing.T) {
+	db := NewDB()
+
+	for i := 0; i < 10; i++ {
+		if err := db.RunInTx(func(tx *Transaction) error {
+			return tx.Set(Key(fmt.Sprintf("k%02d", i)), "v")
+		}); err != nil {
+			t.Fatalf("seed Set: %v", err)
+		}
+	}
+
+	// Take the snapshot before any concurrent writer starts, so the
+	// expected key count below is exact rather than a loose lower bound.
+	tx := db.Begin(false)
+	defer tx.Rollback()
+
+	var wg sync.WaitGroup
+	for i := 10; i < 30; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = db.RunInTx(func(tx *Transaction) error {
+				return tx.Set(Key(fmt.Sprintf("k%02d", i)), "v")
+			})
+		}()
+	}
+	wg.Wait()
+
+	seen := 0
+	err := tx.Ascend(primaryIndex, "", func(key Key, value string) bool {
+		if key >= "k10" {
+			t.Fatalf("snapshot leaked a key committed after it was taken: %s", key)
+		}
+		seen++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Ascend: %v", err)
+	}
+	if seen != 10 {
+		t.Fatalf("expected to see exactly the 10 keys present at snapshot time, got %d", seen)
+	}
+
+	// A fresh snapshot taken after every concurrent writer has committed
+	// must see all 30 keys through the index, not just through Get: each
+	// writer began from its own Begin-time copy-on-write index snapshot,
+	// so a publish that swapped that stale copy straight into db.indexes
+	// would silently drop whichever other writers' entries committed
+	// later, while point lookups via items.storage kept working fine.
+	after := db.Begin(false)
+	defer after.Rollback()
+
+	total := 0
+	err = after.Ascend(primaryIndex, "", func(key Key, value string) bool {
+		total++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Ascend after concurrent commits: %v", err)
+	}
+	if total != 30 {
+		t.Fatalf("expected all 30 keys to survive concurrent commits, got %d", total)
+	}
+}
+
+// TestSeekStart checks that SeekStart walks every key from the beginning,
+// same as Ascend with an empty pivot.
+func TestSeekStart(t *testing.T) {
+	db := NewDB()
+
+	for i := 0; i < 5; i++ {
+		if err := db.RunInTx(func(tx *Transaction) error {
+			return tx.Set(Key(fmt.Sprintf("k%02d", i)), "v")
+		}); err != nil {
+			t.Fatalf("seed Set: %v", err)
+		}
+	}
+
+	tx := db.Begin(false)
+	defer tx.Rollback()
+
+	var keys []Key
+	err := tx.SeekStart(primaryIndex, func(key Key, value string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("SeekStart: %v", err)
+	}
+	if len(keys) != 5 {
+		t.Fatalf("expected 5 keys, got %d", len(keys))
+	}
+}
@@ -0,0 +1,48 @@
+package memdb
+
+import "io"
+
+// OpType identifies the kind of mutation recorded in an Op.
+type OpType uint8
+
+const (
+	// OpSet records that a key was written.
+	OpSet OpType = iota
+	// OpDelete records that a key was removed.
+	OpDelete
+)
+
+// Op is a single mutation belonging to one committed transaction, as
+// appended to a Persistence's write-ahead log and replayed on restore.
+type Op struct {
+	Type  OpType
+	Key   Key
+	Value string
+}
+
+// Persistence durably records committed writes and lets a Database
+// rebuild its state from them. It turns memdb from a pure in-memory
+// store into an optionally durable one without changing the
+// transactional API: NopPersistence is the zero-cost default, and
+// FilePersistence backs a Database with an append-only log plus
+// periodic snapshots.
+type Persistence interface {
+	// AppendCommit is called once per committed writable transaction,
+	// with its ops in commit order.
+	AppendCommit(txID uint64, ops []Op) error
+
+	// Snapshot writes a full point-in-time copy of the database state to w.
+	Snapshot(w io.Writer) error
+
+	// Restore replaces the database's state with what a prior Snapshot wrote.
+	Restore(r io.Reader) error
+}
+
+// NopPersistence is a Persistence that discards everything. It is the
+// default used by NewDB, preserving memdb's original pure in-memory
+// behavior.
+type NopPersistence struct{}
+
+func (NopPersistence) AppendCommit(uint64, []Op) error { return nil }
+func (NopPersistence) Snapshot(io.Writer) error        { return nil }
+func (NopPersistence) Restore(io.Reader) error         { return nil }
@@ -0,0 +1,236 @@
+package memdb
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Transaction is a point-in-time view of the Database at readTs.
+// Read-only transactions (Begin(false)) just see that snapshot and never
+// conflict with anything. Writable transactions (Begin(true)) buffer
+// their writes in pending and track every key they read in readSet, so
+// that Commit can detect whether a concurrent writer committed a
+// conflicting change to one of those keys before this one.
+type Transaction struct {
+	id       uint64
+	db       *Database
+	writable bool
+	done     bool
+
+	readTs     uint64
+	newIndexes *Indexes
+
+	readSet map[Key]struct{}
+	pending map[Key]*dbItem
+}
+
+// Get returns the value visible to this transaction for key, or
+// ErrNotFound if no such value exists at this snapshot.
+func (tx *Transaction) Get(key Key) (string, error) {
+	if tx.writable {
+		if tx.readSet == nil {
+			tx.readSet = make(map[Key]struct{})
+		}
+		tx.readSet[key] = struct{}{}
+
+		if item, ok := tx.pending[key]; ok {
+			if item.deletedTx != 0 {
+				return "", ErrNotFound
+			}
+			return item.value, nil
+		}
+	}
+
+	// A key can have several versions in storage at once (an update
+	// doesn't synchronously delete the version it supersedes), so pick
+	// the newest one visible to this transaction rather than the first
+	// one found — otherwise Get can return a stale value that was
+	// already overwritten by an earlier commit.
+	var newest *dbItem
+	for _, item := range tx.db.items.get(key) {
+		item := item
+		if !tx.visible(&item) {
+			continue
+		}
+		if newest == nil || item.createdTx > newest.createdTx {
+			newest = &item
+		}
+	}
+
+	// A visible version is live as of readTs even if a later commit has
+	// since set its deletedTx; a committed delete is never visible itself.
+	if newest == nil {
+		return "", ErrNotFound
+	}
+	return newest.value, nil
+}
+
+// visible reports whether item was already created, and not yet
+// deleted, as of this transaction's read timestamp.
+func (tx *Transaction) visible(item *dbItem) bool {
+	if item.createdTx > tx.readTs {
+		return false
+	}
+	if item.deletedTx != 0 && item.deletedTx <= tx.readTs {
+		return false
+	}
+	return true
+}
+
+// Set buffers a write of key=value. The write is visible to this
+// transaction immediately but is only published to the Database, and to
+// other transactions, once Commit succeeds.
+func (tx *Transaction) Set(key Key, value string) error {
+	if !tx.writable {
+		return ErrTxNotWritable
+	}
+	if tx.done {
+		return ErrTxDone
+	}
+
+	if tx.pending == nil {
+		tx.pending = make(map[Key]*dbItem)
+	}
+	item := &dbItem{key: key, value: value, createdTx: maxTs}
+	tx.pending[key] = item
+	tx.indexPending(item)
+
+	return nil
+}
+
+// Delete buffers the removal of key. Like Set, it is only published on
+// Commit.
+func (tx *Transaction) Delete(key Key) error {
+	if !tx.writable {
+		return ErrTxNotWritable
+	}
+	if tx.done {
+		return ErrTxDone
+	}
+
+	if tx.pending == nil {
+		tx.pending = make(map[Key]*dbItem)
+	}
+	item := &dbItem{key: key, deletedTx: tx.id, createdTx: maxTs}
+	tx.pending[key] = item
+	tx.indexPending(item)
+
+	return nil
+}
+
+// indexPending inserts item into this transaction's own index view so
+// that Ascend/Descend/AscendRange/AscendPrefix see it immediately,
+// matching the read-your-writes behavior Get already has for point
+// lookups. item.createdTx is the maxTs sentinel at this point (the real
+// commitTs is only stamped into it at Commit), which sorts it before
+// every already-committed version of the same key — see visit, which
+// recognizes it via tx.pending rather than the usual visible() check.
+func (tx *Transaction) indexPending(item *dbItem) {
+	for _, idx := range tx.newIndexes.byName {
+		idx.data.ReplaceOrInsert(item)
+	}
+}
+
+// Commit validates this transaction's read-set against everything
+// committed since it began and, if there is no conflict, publishes its
+// buffered writes. It returns ErrConflict if another transaction
+// committed a write to a key this one read; Database.RunInTx retries
+// automatically on that error.
+func (tx *Transaction) Commit() error {
+	if !tx.writable {
+		return ErrTxNotWritable
+	}
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	status := StatusDone
+	defer tx.db.oracle.done(tx.readTs)
+	defer func() { tx.db.writers.set(tx.id, status) }()
+
+	if len(tx.pending) == 0 {
+		return nil
+	}
+
+	written := make([]Key, 0, len(tx.pending))
+	for key := range tx.pending {
+		written = append(written, key)
+	}
+
+	commitTs, err := tx.db.oracle.commit(tx.readTs, tx.readSet, written)
+	if err != nil {
+		status = StatusRollback
+		return err
+	}
+
+	ops := make([]Op, 0, len(tx.pending))
+	for key, item := range tx.pending {
+		item.createdTx = commitTs
+		if item.deletedTx != 0 {
+			item.deletedTx = commitTs
+			ops = append(ops, Op{Type: OpDelete, Key: key})
+		} else {
+			ops = append(ops, Op{Type: OpSet, Key: key, Value: item.value})
+		}
+	}
+
+	if err := tx.db.persistence.AppendCommit(tx.id, ops); err != nil {
+		return err
+	}
+
+	// Publish against the database's *current* indexes, taken fresh under
+	// indexesMu rather than the copy-on-write clone tx.newIndexes took
+	// back at Begin. Swapping that stale clone straight into db.indexes
+	// would discard every index entry any other writer published between
+	// this transaction's Begin and Commit — indexesMu serializes the
+	// whole read-clone-apply-swap sequence so every commit's clone is
+	// always based on the latest published indexes, not a stale one.
+	tx.db.indexesMu.Lock()
+	published := tx.db.indexes.Copy()
+
+	for key, item := range tx.pending {
+		// Mark whatever live version of key is already in storage as
+		// superseded before adding the new one, so Get and GC only ever
+		// see one live version of a key at a time.
+		tx.db.items.supersede(key, commitTs)
+		tx.db.items.set(key, item)
+		for _, idx := range published.byName {
+			idx.data.ReplaceOrInsert(item)
+		}
+	}
+
+	tx.db.indexes = published
+	tx.db.indexesMu.Unlock()
+
+	atomic.StoreInt64(&tx.db.lastUpdatedUnixNano, time.Now().UnixNano())
+
+	for _, op := range ops {
+		ev := Event{Key: op.Key, Value: op.Value, TxID: tx.id}
+		if op.Type == OpDelete {
+			ev.Type = EventDelete
+		} else {
+			ev.Type = EventPut
+		}
+		tx.db.watchers.publish(ev)
+	}
+
+	return nil
+}
+
+// Rollback discards a transaction's buffered writes without publishing
+// them. It is always safe to call and is a no-op on a transaction that
+// already committed or rolled back.
+func (tx *Transaction) Rollback() {
+	if tx.done {
+		return
+	}
+	tx.done = true
+	tx.pending = nil
+
+	tx.db.oracle.done(tx.readTs)
+	if tx.writable {
+		tx.db.writers.set(tx.id, StatusRollback)
+	} else {
+		tx.db.writers.set(tx.id, StatusDone)
+	}
+}
@@ -0,0 +1,114 @@
+package memdb
+
+import "sort"
+
+// itemTree is a sorted, copy-on-write collection of *dbItem backing
+// each Index. This module ships no go.mod/vendor to pin an external
+// btree dependency to a compatible version, so rather than taking on
+// one that may silently drift out from under us, itemTree implements
+// the handful of ordered operations memdb actually needs directly over
+// a sorted slice: ordered insertion, ascend/descend walks, and a Clone
+// for per-transaction copy-on-write isolation.
+type itemTree struct {
+	items []*dbItem
+	less  func(a, b *dbItem) bool
+}
+
+func newItemTree(less func(a, b *dbItem) bool) *itemTree {
+	return &itemTree{less: less}
+}
+
+// Clone returns an independent copy of the tree; mutating one afterward
+// never affects the other.
+func (t *itemTree) Clone() *itemTree {
+	items := make([]*dbItem, len(t.items))
+	copy(items, t.items)
+	return &itemTree{items: items, less: t.less}
+}
+
+// lowerBound returns the index of the first item not less than pivot.
+func (t *itemTree) lowerBound(pivot *dbItem) int {
+	return sort.Search(len(t.items), func(i int) bool {
+		return !t.less(t.items[i], pivot)
+	})
+}
+
+// upperBound returns the index of the first item greater than pivot.
+func (t *itemTree) upperBound(pivot *dbItem) int {
+	return sort.Search(len(t.items), func(i int) bool {
+		return t.less(pivot, t.items[i])
+	})
+}
+
+// ReplaceOrInsert inserts item in sorted order. Every index's less
+// breaks ties on createdTx, so two distinct MVCC versions never compare
+// equal and this always inserts a new slot rather than overwriting one.
+func (t *itemTree) ReplaceOrInsert(item *dbItem) {
+	i := t.lowerBound(item)
+	t.items = append(t.items, nil)
+	copy(t.items[i+1:], t.items[i:])
+	t.items[i] = item
+}
+
+// Remove drops every item in drop from the tree, preserving the order of
+// what remains.
+func (t *itemTree) Remove(drop map[*dbItem]struct{}) {
+	if len(drop) == 0 {
+		return
+	}
+
+	kept := t.items[:0]
+	for _, item := range t.items {
+		if _, gone := drop[item]; gone {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	t.items = kept
+}
+
+// Ascend walks every item in ascending order until iter returns false.
+func (t *itemTree) Ascend(iter func(item *dbItem) bool) {
+	for _, item := range t.items {
+		if !iter(item) {
+			return
+		}
+	}
+}
+
+// Descend walks every item in descending order until iter returns false.
+func (t *itemTree) Descend(iter func(item *dbItem) bool) {
+	for i := len(t.items) - 1; i >= 0; i-- {
+		if !iter(t.items[i]) {
+			return
+		}
+	}
+}
+
+// AscendGreaterOrEqual walks items >= pivot in ascending order.
+func (t *itemTree) AscendGreaterOrEqual(pivot *dbItem, iter func(item *dbItem) bool) {
+	for i := t.lowerBound(pivot); i < len(t.items); i++ {
+		if !iter(t.items[i]) {
+			return
+		}
+	}
+}
+
+// DescendLessOrEqual walks items <= pivot in descending order.
+func (t *itemTree) DescendLessOrEqual(pivot *dbItem, iter func(item *dbItem) bool) {
+	for i := t.upperBound(pivot) - 1; i >= 0; i-- {
+		if !iter(t.items[i]) {
+			return
+		}
+	}
+}
+
+// AscendRange walks items in [greaterOrEqual, lessThan) in ascending order.
+func (t *itemTree) AscendRange(greaterOrEqual, lessThan *dbItem, iter func(item *dbItem) bool) {
+	end := t.lowerBound(lessThan)
+	for i := t.lowerBound(greaterOrEqual); i < end; i++ {
+		if !iter(t.items[i]) {
+			return
+		}
+	}
+}
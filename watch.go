@@ -0,0 +1,87 @@
+package memdb
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// EventType identifies what kind of change a watch Event represents.
+type EventType uint8
+
+const (
+	EventPut EventType = iota
+	EventDelete
+)
+
+// Event describes a single key change from a committed writable
+// transaction, delivered to subscribers registered with Database.Watch.
+type Event struct {
+	Type  EventType
+	Key   Key
+	Value string
+	TxID  uint64
+}
+
+// watchBufferSize is how many pending events a subscriber's channel
+// holds before new events for it start being dropped rather than
+// blocking the committing transaction.
+const watchBufferSize = 64
+
+// watcher is one subscription registered through Database.Watch.
+type watcher struct {
+	prefix Key
+	ch     chan Event
+}
+
+// watchers holds every active subscription and counts events dropped
+// because a subscriber's channel was full, surfaced through Stats().
+type watchers struct {
+	mu      sync.RWMutex
+	subs    map[*watcher]struct{}
+	dropped int64
+}
+
+func newWatchers() *watchers {
+	return &watchers{subs: make(map[*watcher]struct{})}
+}
+
+// Watch subscribes to every Put/Delete on keys with the given prefix,
+// emitted after the writable transaction that made them commits. The
+// returned cancel func stops the subscription and releases its channel;
+// callers must call it when done watching.
+func (db *Database) Watch(prefix Key) (<-chan Event, func()) {
+	w := &watcher{prefix: prefix, ch: make(chan Event, watchBufferSize)}
+
+	db.watchers.mu.Lock()
+	db.watchers.subs[w] = struct{}{}
+	db.watchers.mu.Unlock()
+
+	cancel := func() {
+		db.watchers.mu.Lock()
+		delete(db.watchers.subs, w)
+		db.watchers.mu.Unlock()
+		close(w.ch)
+	}
+
+	return w.ch, cancel
+}
+
+// publish fans ev out to every watcher whose prefix matches, dropping it
+// (and counting the drop) for any subscriber whose channel is full
+// rather than blocking the committing transaction.
+func (ws *watchers) publish(ev Event) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	for w := range ws.subs {
+		if !strings.HasPrefix(string(ev.Key), string(w.prefix)) {
+			continue
+		}
+		select {
+		case w.ch <- ev:
+		default:
+			atomic.AddInt64(&ws.dropped, 1)
+		}
+	}
+}